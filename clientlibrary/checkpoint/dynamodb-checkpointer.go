@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package checkpoint persists shard checkpoints to the DynamoDB lease
+// table. It is shared by every worker implementation in this module
+// (Kinesis polling/fan-out, DynamoDB Streams) so they all checkpoint the
+// same way regardless of which stream API they read records from.
+package checkpoint
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// ShardEndCheckpoint marks a shard as fully processed. A nil sequence
+// number (passed when a TERMINATE shutdown checkpoints) is not a valid
+// DynamoDB attribute value, so it's stored as this sentinel instead.
+const ShardEndCheckpoint = "SHARD_END"
+
+// DynamoCheckpointer persists a single shard's checkpoint as an attribute
+// on the lease table, keyed by shard ID. It satisfies
+// interfaces.IRecordProcessorCheckpointer.
+type DynamoCheckpointer struct {
+	DBClient  dynamodbiface.DynamoDBAPI
+	TableName string
+	WorkerID  string
+	ShardID   string
+}
+
+// NewDynamoCheckpointer returns a checkpointer for a single shard of tableName.
+func NewDynamoCheckpointer(dbClient dynamodbiface.DynamoDBAPI, tableName, workerID, shardID string) *DynamoCheckpointer {
+	return &DynamoCheckpointer{
+		DBClient:  dbClient,
+		TableName: tableName,
+		WorkerID:  workerID,
+		ShardID:   shardID,
+	}
+}
+
+// Checkpoint persists sequenceNumber as the last record processed for this
+// shard. A nil sequenceNumber checkpoints at the end of the shard.
+func (c *DynamoCheckpointer) Checkpoint(sequenceNumber *string) error {
+	checkpoint := aws.StringValue(sequenceNumber)
+	if sequenceNumber == nil {
+		checkpoint = ShardEndCheckpoint
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(c.ShardID)},
+		},
+		UpdateExpression: aws.String("SET Checkpoint = :checkpoint, LeaseOwner = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":checkpoint": {S: aws.String(checkpoint)},
+			":owner":      {S: aws.String(c.WorkerID)},
+		},
+	}
+
+	_, err := c.DBClient.UpdateItem(input)
+	return err
+}
+
+// GetCheckpoint returns the last checkpointed sequence number for shardID,
+// or the empty string if the shard has never been checkpointed.
+func GetCheckpoint(dbClient dynamodbiface.DynamoDBAPI, tableName, shardID string) (string, error) {
+	out, err := dbClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(shardID)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if out.Item == nil || out.Item["Checkpoint"] == nil {
+		return "", nil
+	}
+
+	return aws.StringValue(out.Item["Checkpoint"].S), nil
+}
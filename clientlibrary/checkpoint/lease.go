@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package checkpoint
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Lease is a single shard's row in the lease table, as read by ListLeases.
+type Lease struct {
+	ShardID      string
+	LeaseOwner   string
+	DesiredOwner string
+	Checkpoint   string
+}
+
+// TryAcquireLease conditionally claims shardID for workerID: the claim
+// succeeds if the shard is unowned, already owned by workerID, or its
+// DesiredOwner hint names workerID (a rebalance hint left by the elected
+// leader - see package leaderelection). Taking over an unowned or hinted
+// shard clears the DesiredOwner hint; renewing a lease workerID already
+// holds leaves a hint naming another worker in place. A failed condition
+// is not an error; it just means another worker won the race, and is
+// reported as acquired=false.
+func TryAcquireLease(dbClient dynamodbiface.DynamoDBAPI, tableName, shardID, workerID string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(shardID)},
+		},
+		UpdateExpression: aws.String("SET LeaseOwner = :owner REMOVE DesiredOwner"),
+		ConditionExpression: aws.String(
+			"attribute_not_exists(LeaseOwner) OR DesiredOwner = :owner",
+		),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(workerID)},
+		},
+	}
+	_, err := dbClient.UpdateItem(input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Not a takeover; renew the lease if workerID already owns it.
+		input.UpdateExpression = aws.String("SET LeaseOwner = :owner")
+		input.ConditionExpression = aws.String("LeaseOwner = :owner")
+		_, err = dbClient.UpdateItem(input)
+	}
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetDesiredOwner leaves a rebalance hint naming workerID as the intended
+// owner of shardID, without otherwise disturbing the current lease. The
+// current owner keeps the lease until the named worker calls
+// TryAcquireLease and steals it.
+func SetDesiredOwner(dbClient dynamodbiface.DynamoDBAPI, tableName, shardID, workerID string) error {
+	_, err := dbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(shardID)},
+		},
+		UpdateExpression: aws.String("SET DesiredOwner = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(workerID)},
+		},
+	})
+	return err
+}
+
+// workerHeartbeatPrefix marks a lease-table row as a worker heartbeat
+// rather than a real shard lease, the same way leaderelection reserves
+// its own "__leader__" row for the leader lock. Heartbeat rows carry no
+// LeaseOwner, so callers that scan for leases and skip unowned shards
+// already ignore them without any extra filtering.
+const workerHeartbeatPrefix = "__worker__"
+
+// WorkerHeartbeat is a single worker's liveness row, as read by
+// ListWorkerHeartbeats.
+type WorkerHeartbeat struct {
+	WorkerID string
+	LastSeen time.Time
+}
+
+// HeartbeatWorker records that workerID is still alive, so the
+// leader-elected rebalancer can treat it as a rebalance recipient even
+// while it holds no leases - e.g. because it just joined the fleet or is
+// recovering from a crash. Callers should call this on the same cadence
+// as ShardSyncIntervalMillis.
+func HeartbeatWorker(dbClient dynamodbiface.DynamoDBAPI, tableName, workerID string) error {
+	_, err := dbClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"ShardID":  {S: aws.String(workerHeartbeatPrefix + workerID)},
+			"WorkerID": {S: aws.String(workerID)},
+			"LastSeen": {S: aws.String(time.Now().UTC().Format(time.RFC3339Nano))},
+		},
+	})
+	return err
+}
+
+// ListWorkerHeartbeats scans the lease table for worker heartbeat rows,
+// e.g. for the elected leader to find workers that are alive but
+// currently hold no leases.
+func ListWorkerHeartbeats(dbClient dynamodbiface.DynamoDBAPI, tableName string) ([]WorkerHeartbeat, error) {
+	var heartbeats []WorkerHeartbeat
+	var lastKey map[string]*dynamodb.AttributeValue
+
+	for {
+		out, err := dbClient.Scan(&dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			shardID := aws.StringValue(item["ShardID"].S)
+			if !strings.HasPrefix(shardID, workerHeartbeatPrefix) {
+				continue
+			}
+
+			lastSeen, err := time.Parse(time.RFC3339Nano, aws.StringValue(item["LastSeen"].S))
+			if err != nil {
+				continue
+			}
+
+			heartbeats = append(heartbeats, WorkerHeartbeat{
+				WorkerID: aws.StringValue(item["WorkerID"].S),
+				LastSeen: lastSeen,
+			})
+		}
+
+		lastKey = out.LastEvaluatedKey
+		if lastKey == nil {
+			break
+		}
+	}
+
+	return heartbeats, nil
+}
+
+// ListLeases scans the full lease table, e.g. for the elected leader to
+// compute a balanced assignment across workers.
+func ListLeases(dbClient dynamodbiface.DynamoDBAPI, tableName string) ([]Lease, error) {
+	var leases []Lease
+	var lastKey map[string]*dynamodb.AttributeValue
+
+	for {
+		out, err := dbClient.Scan(&dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range out.Items {
+			shardID := aws.StringValue(item["ShardID"].S)
+			if shardID == "" {
+				continue
+			}
+
+			lease := Lease{ShardID: shardID}
+			if v, ok := item["LeaseOwner"]; ok {
+				lease.LeaseOwner = aws.StringValue(v.S)
+			}
+			if v, ok := item["DesiredOwner"]; ok {
+				lease.DesiredOwner = aws.StringValue(v.S)
+			}
+			if v, ok := item["Checkpoint"]; ok {
+				lease.Checkpoint = aws.StringValue(v.S)
+			}
+
+			leases = append(leases, lease)
+		}
+
+		lastKey = out.LastEvaluatedKey
+		if lastKey == nil {
+			break
+		}
+	}
+
+	return leases, nil
+}
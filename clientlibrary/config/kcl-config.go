@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package config holds the configuration knobs that control how the worker
+// discovers, leases and consumes shards.
+package config
+
+import (
+	"github.com/aws/aws-sdk-go/aws/credentials"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/leaderelection"
+)
+
+// InitialPositionInStream identifies where to start reading from a shard
+// that does not yet have a checkpoint.
+type InitialPositionInStream int
+
+const (
+	// LATEST starts reading just after the most recent record in the shard.
+	LATEST InitialPositionInStream = iota + 1
+
+	// TRIM_HORIZON starts reading at the oldest record still retained by the shard.
+	TRIM_HORIZON
+)
+
+const (
+	// DefaultMaxRecords is the default number of records requested per GetRecords call.
+	DefaultMaxRecords = 10000
+
+	// DefaultMaxLeasesForWorker is the default cap on the number of shards a single worker will lease.
+	DefaultMaxLeasesForWorker = 1
+
+	// DefaultShardSyncIntervalMillis is the default interval between shard sync/lease-stealing passes.
+	DefaultShardSyncIntervalMillis = 60000
+
+	// DefaultFailoverTimeMillis is the default duration after which a worker heartbeat is considered stale.
+	DefaultFailoverTimeMillis = 10000
+
+	// DefaultMetricsBufferTimeMillis is the default flush interval for buffered metrics.
+	DefaultMetricsBufferTimeMillis = 10000
+
+	// DefaultMetricsMaxQueueSize is the default size of the buffered metrics queue.
+	DefaultMetricsMaxQueueSize = 10000
+)
+
+// KinesisClientLibConfiguration holds everything the worker needs to
+// locate its stream, lease table and credentials, along with tunables
+// for polling cadence and failover behavior. Instances are built with
+// NewKinesisClientLibConfig and customized through the With* chain.
+type KinesisClientLibConfiguration struct {
+	ApplicationName string
+	StreamName      string
+	RegionName      string
+	WorkerID        string
+
+	KinesisCredentials    *credentials.Credentials
+	DynamoDBCredentials   *credentials.Credentials
+	CloudWatchCredentials *credentials.Credentials
+
+	InitialPositionInStream InitialPositionInStream
+
+	MaxRecords              int
+	MaxLeasesForWorker      int
+	ShardSyncIntervalMillis int
+	FailoverTimeMillis      int
+
+	MetricsBufferTimeMillis int
+	MetricsMaxQueueSize     int
+
+	// EnableEnhancedFanOut switches shard consumption from polling GetRecords
+	// to the HTTP/2 push-based SubscribeToShard API. See WithEnhancedFanOut.
+	EnableEnhancedFanOut       bool
+	EnhancedFanOutConsumerName string
+
+	// KinesisEndpoint, DynamoDBEndpoint and CloudWatchEndpoint override the
+	// default AWS service endpoints, e.g. to point the worker at a
+	// LocalStack instance for integration testing. Leave empty to use the
+	// AWS SDK's default endpoint resolution.
+	KinesisEndpoint    string
+	DynamoDBEndpoint   string
+	CloudWatchEndpoint string
+
+	// S3ForcePathStyle forces path-style addressing (as opposed to the
+	// default virtual-hosted-style) for service clients, which most
+	// non-AWS endpoint emulators such as LocalStack require.
+	S3ForcePathStyle bool
+
+	// EnableLeaderElection turns on the optional leader-elected
+	// rebalancer. See WithLeaderElection.
+	EnableLeaderElection bool
+	LeaderElector        leaderelection.LeaderElector
+}
+
+// NewKinesisClientLibConfig returns a configuration that uses the default
+// AWS credential provider chain.
+func NewKinesisClientLibConfig(applicationName, streamName, regionName, workerID string) *KinesisClientLibConfiguration {
+	return NewKinesisClientLibConfigWithCredential(applicationName, streamName, regionName, workerID, nil)
+}
+
+// NewKinesisClientLibConfigWithCredential returns a configuration that uses
+// the supplied credentials for Kinesis, DynamoDB and CloudWatch, in the
+// absence of more specific With*Credentials overrides.
+func NewKinesisClientLibConfigWithCredential(applicationName, streamName, regionName, workerID string, creds *credentials.Credentials) *KinesisClientLibConfiguration {
+	return &KinesisClientLibConfiguration{
+		ApplicationName: applicationName,
+		StreamName:      streamName,
+		RegionName:      regionName,
+		WorkerID:        workerID,
+
+		KinesisCredentials:    creds,
+		DynamoDBCredentials:   creds,
+		CloudWatchCredentials: creds,
+
+		InitialPositionInStream: LATEST,
+
+		MaxRecords:              DefaultMaxRecords,
+		MaxLeasesForWorker:      DefaultMaxLeasesForWorker,
+		ShardSyncIntervalMillis: DefaultShardSyncIntervalMillis,
+		FailoverTimeMillis:      DefaultFailoverTimeMillis,
+
+		MetricsBufferTimeMillis: DefaultMetricsBufferTimeMillis,
+		MetricsMaxQueueSize:     DefaultMetricsMaxQueueSize,
+	}
+}
+
+// WithInitialPositionInStream sets where a shard with no checkpoint starts reading from.
+func (c *KinesisClientLibConfiguration) WithInitialPositionInStream(pos InitialPositionInStream) *KinesisClientLibConfiguration {
+	c.InitialPositionInStream = pos
+	return c
+}
+
+// WithMaxRecords caps the number of records requested per GetRecords call.
+func (c *KinesisClientLibConfiguration) WithMaxRecords(maxRecords int) *KinesisClientLibConfiguration {
+	c.MaxRecords = maxRecords
+	return c
+}
+
+// WithMaxLeasesForWorker caps the number of shards this worker will lease at once.
+func (c *KinesisClientLibConfiguration) WithMaxLeasesForWorker(maxLeases int) *KinesisClientLibConfiguration {
+	c.MaxLeasesForWorker = maxLeases
+	return c
+}
+
+// WithShardSyncIntervalMillis sets how often the worker re-syncs shards and leases.
+func (c *KinesisClientLibConfiguration) WithShardSyncIntervalMillis(millis int) *KinesisClientLibConfiguration {
+	c.ShardSyncIntervalMillis = millis
+	return c
+}
+
+// WithFailoverTimeMillis sets how long a worker may go without a heartbeat
+// before the leader-elected rebalancer stops counting it as live. Leases
+// themselves do not expire: an owned lease passes to another worker only
+// through a rebalance hint, not because its owner stopped renewing it.
+func (c *KinesisClientLibConfiguration) WithFailoverTimeMillis(millis int) *KinesisClientLibConfiguration {
+	c.FailoverTimeMillis = millis
+	return c
+}
+
+// WithMetricsBufferTimeMillis sets how often buffered metrics are flushed.
+func (c *KinesisClientLibConfiguration) WithMetricsBufferTimeMillis(millis int) *KinesisClientLibConfiguration {
+	c.MetricsBufferTimeMillis = millis
+	return c
+}
+
+// WithMetricsMaxQueueSize sets the size of the buffered metrics queue.
+func (c *KinesisClientLibConfiguration) WithMetricsMaxQueueSize(size int) *KinesisClientLibConfiguration {
+	c.MetricsMaxQueueSize = size
+	return c
+}
+
+// WithEnhancedFanOut switches the worker from polling GetRecords to the
+// HTTP/2 push-based SubscribeToShard API for every shard it leases.
+// consumerName is registered (or looked up, if already registered) via
+// RegisterStreamConsumer/DescribeStreamConsumer against StreamName.
+//
+// If the consumer ARN cannot be resolved, or the account has already
+// registered the maximum of 5 consumers on the stream, the worker falls
+// back to polling rather than failing Start().
+func (c *KinesisClientLibConfiguration) WithEnhancedFanOut(consumerName string) *KinesisClientLibConfiguration {
+	c.EnableEnhancedFanOut = true
+	c.EnhancedFanOutConsumerName = consumerName
+	return c
+}
+
+// WithKinesisEndpoint overrides the Kinesis service endpoint, e.g.
+// "http://localhost:4566" to run against LocalStack.
+func (c *KinesisClientLibConfiguration) WithKinesisEndpoint(endpoint string) *KinesisClientLibConfiguration {
+	c.KinesisEndpoint = endpoint
+	return c
+}
+
+// WithDynamoDBEndpoint overrides the DynamoDB service endpoint used for
+// both the lease table and checkpointing.
+func (c *KinesisClientLibConfiguration) WithDynamoDBEndpoint(endpoint string) *KinesisClientLibConfiguration {
+	c.DynamoDBEndpoint = endpoint
+	return c
+}
+
+// WithCloudWatchEndpoint overrides the CloudWatch service endpoint used
+// by the CloudWatch monitoring service.
+func (c *KinesisClientLibConfiguration) WithCloudWatchEndpoint(endpoint string) *KinesisClientLibConfiguration {
+	c.CloudWatchEndpoint = endpoint
+	return c
+}
+
+// WithS3ForcePathStyle toggles path-style addressing for service clients,
+// which most non-AWS endpoint emulators such as LocalStack require.
+func (c *KinesisClientLibConfiguration) WithS3ForcePathStyle(forcePathStyle bool) *KinesisClientLibConfiguration {
+	c.S3ForcePathStyle = forcePathStyle
+	return c
+}
+
+// WithLeaderElection replaces KCL's decentralized, greedy lease-stealing
+// with a single elected leader that periodically rebalances leases across
+// the fleet. Pass nil to use the default DynamoDB-lock-based elector
+// (backed by the worker's own lease table); pass a custom
+// leaderelection.LeaderElector to wire in an external primitive such as a
+// Kubernetes coordination.k8s.io lease.
+func (c *KinesisClientLibConfiguration) WithLeaderElection(elector leaderelection.LeaderElector) *KinesisClientLibConfiguration {
+	c.EnableLeaderElection = true
+	c.LeaderElector = elector
+	return c
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package interfaces defines the contract record processors implement, and
+// the inputs the worker hands them at each stage of a shard's lifecycle.
+package interfaces
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+)
+
+// Record is a single change record read off a shard. It mirrors the
+// DynamoDB Streams record shape directly so record processors don't have
+// to reach through a Kinesis Adapter shim to get at NewImage/OldImage.
+type Record = dynamodbstreams.Record
+
+// ExtendedSequenceNumber identifies a position within a shard, including
+// the sub-sequence number used to order records within the same parent
+// sequence number after a shard split/merge.
+type ExtendedSequenceNumber struct {
+	SequenceNumber    *string
+	SubSequenceNumber *int64
+}
+
+// InitializationInput is passed to IRecordProcessor.Initialize before the
+// first call to ProcessRecords for a shard.
+type InitializationInput struct {
+	ShardId                         string
+	ExtendedSequenceNumber          *ExtendedSequenceNumber
+	PendingCheckpointSequenceNumber *ExtendedSequenceNumber
+}
+
+// ProcessRecordsInput is passed to IRecordProcessor.ProcessRecords for
+// each batch of records read off a shard.
+type ProcessRecordsInput struct {
+	Records            []*Record
+	MillisBehindLatest int64
+	Checkpointer       IRecordProcessorCheckpointer
+}
+
+// ShutdownReason indicates why a record processor's shard is being shut down.
+type ShutdownReason int
+
+const (
+	// ZOMBIE indicates the shard's lease was lost to another worker; the
+	// caller must not checkpoint.
+	ZOMBIE ShutdownReason = iota + 1
+
+	// TERMINATE indicates the shard has been fully processed and closed
+	// (it was split or merged); the caller must checkpoint.
+	TERMINATE
+)
+
+// ShutdownReasonMessage renders a ShutdownReason for logging.
+func ShutdownReasonMessage(reason ShutdownReason) *string {
+	switch reason {
+	case ZOMBIE:
+		return aws.String("ZOMBIE")
+	case TERMINATE:
+		return aws.String("TERMINATE")
+	default:
+		return aws.String("UNKNOWN")
+	}
+}
+
+// ShutdownInput is passed to IRecordProcessor.Shutdown when a shard is
+// being abandoned (ZOMBIE) or has been fully processed (TERMINATE).
+type ShutdownInput struct {
+	ShutdownReason ShutdownReason
+	Checkpointer   IRecordProcessorCheckpointer
+}
+
+// IRecordProcessorCheckpointer lets a record processor persist its
+// progress through a shard so that a restart or failover resumes instead
+// of reprocessing.
+type IRecordProcessorCheckpointer interface {
+	// Checkpoint persists sequenceNumber as the last record processed. A
+	// nil sequenceNumber checkpoints at the end of the shard and is only
+	// valid in response to a TERMINATE shutdown.
+	Checkpoint(sequenceNumber *string) error
+}
+
+// IRecordProcessor is implemented by application code to consume records
+// from a single shard. The worker creates one instance per leased shard
+// via IRecordProcessorFactory and drives it through Initialize,
+// ProcessRecords (repeatedly) and finally Shutdown.
+type IRecordProcessor interface {
+	Initialize(input *InitializationInput)
+	ProcessRecords(input *ProcessRecordsInput)
+	Shutdown(input *ShutdownInput)
+}
+
+// IRecordProcessorFactory creates one IRecordProcessor per shard the
+// worker leases.
+type IRecordProcessorFactory interface {
+	CreateProcessor() IRecordProcessor
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package leaderelection
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// leaderShardID is the sentinel lease-table row used as the leader lock.
+// It can never collide with a real shard ID, which AWS generates as
+// "shardId-<12 digits>...".
+const leaderShardID = "__leader__"
+
+// DefaultLeaseDuration is how long a leader lock is held without renewal
+// before another worker may take it over.
+const DefaultLeaseDuration = 30 * time.Second
+
+// DynamoDBLeaderElector elects a leader via a conditional-write lock row
+// in the worker's existing DynamoDB lease table, so no additional
+// infrastructure is required to use it.
+type DynamoDBLeaderElector struct {
+	DBClient      dynamodbiface.DynamoDBAPI
+	TableName     string
+	LeaseDuration time.Duration
+}
+
+// NewDynamoDBLeaderElector returns a LeaderElector backed by tableName,
+// the same DynamoDB table the worker already uses for shard leases.
+func NewDynamoDBLeaderElector(dbClient dynamodbiface.DynamoDBAPI, tableName string) *DynamoDBLeaderElector {
+	return &DynamoDBLeaderElector{
+		DBClient:      dbClient,
+		TableName:     tableName,
+		LeaseDuration: DefaultLeaseDuration,
+	}
+}
+
+// Campaign satisfies LeaderElector.
+func (e *DynamoDBLeaderElector) Campaign(workerID string) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(e.LeaseDuration).UnixNano()
+
+	_, err := e.DBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(e.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(leaderShardID)},
+		},
+		UpdateExpression: aws.String("SET LeaderID = :id, LeaseExpiresAt = :expires"),
+		ConditionExpression: aws.String(
+			"attribute_not_exists(LeaderID) OR LeaderID = :id OR LeaseExpiresAt < :now",
+		),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id":      {S: aws.String(workerID)},
+			":expires": {N: aws.String(strconv.FormatInt(expiresAt, 10))},
+			":now":     {N: aws.String(strconv.FormatInt(now.UnixNano(), 10))},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// StepDown satisfies LeaderElector. It only clears the lock row if
+// workerID is still the recorded leader, so a stale renewal failure can't
+// clobber whichever worker has since taken over.
+func (e *DynamoDBLeaderElector) StepDown(workerID string) error {
+	_, err := e.DBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(e.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: aws.String(leaderShardID)},
+		},
+		UpdateExpression:    aws.String("REMOVE LeaderID, LeaseExpiresAt"),
+		ConditionExpression: aws.String("LeaderID = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {S: aws.String(workerID)},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package leaderelection elects a single worker to balance shard leases
+// across the fleet. KCL's default lease-stealing is decentralized and
+// greedy, which produces uneven distribution and thundering-herd
+// rebalances after failovers; electing one leader to compute a target
+// assignment avoids both.
+package leaderelection
+
+// LeaderElector is the pluggable leader-election primitive used by the
+// worker's rebalancer. The default implementation (see
+// NewDynamoDBLeaderElector) uses a conditional-write lock row in the
+// existing lease table; callers may instead supply their own, e.g. to
+// wire in Kubernetes coordination.k8s.io leases.
+type LeaderElector interface {
+	// Campaign attempts to become (or remain) leader for workerID. It
+	// returns whether workerID holds leadership after the call.
+	Campaign(workerID string) (isLeader bool, err error)
+
+	// StepDown releases leadership held by workerID, if any. Callers
+	// invoke this when leadership renewal fails, so another worker can
+	// take over without waiting out the full lease duration.
+	StepDown(workerID string) error
+}
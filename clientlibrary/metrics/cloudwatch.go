@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	log "github.com/sirupsen/logrus"
+
+	cfg "github.com/vmware/vmware-go-kcl/clientlibrary/config"
+)
+
+// cloudWatchNamespace is the CloudWatch namespace every metric emitted by
+// CloudWatchMonitoringService is published under.
+const cloudWatchNamespace = "KCL"
+
+// cloudWatchMaxDatumsPerCall is the maximum number of MetricDatum entries
+// CloudWatch accepts in a single PutMetricData call.
+const cloudWatchMaxDatumsPerCall = 20
+
+// Init satisfies MonitoringService. It starts a background goroutine that
+// flushes buffered counters to CloudWatch every MetricsBufferTimeMillis, or
+// as soon as MetricsMaxQueueSize datums have accumulated, whichever comes
+// first - so a burst of counter calls costs one PutMetricData call per
+// cloudWatchMaxDatumsPerCall datums instead of one per counter.
+func (cw *CloudWatchMonitoringService) Init() error {
+	sess, err := session.NewSession(&aws.Config{Credentials: cw.Credentials})
+	if err != nil {
+		return err
+	}
+
+	config := &aws.Config{Credentials: cw.Credentials}
+	if cw.Endpoint != "" {
+		config.Endpoint = aws.String(cw.Endpoint)
+	}
+
+	cw.client = cloudwatch.New(sess, config)
+
+	bufferTime := cw.MetricsBufferTimeMillis
+	if bufferTime <= 0 {
+		bufferTime = cfg.DefaultMetricsBufferTimeMillis
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(bufferTime) * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			cw.flush()
+		}
+	}()
+
+	log.Debugf("Initializing CloudWatch monitoring service")
+	return nil
+}
+
+// putMetric enqueues a single CloudWatch datum, flushing immediately if the
+// buffer has reached MetricsMaxQueueSize.
+func (cw *CloudWatchMonitoringService) putMetric(name, unit string, value float64, dimensions ...*cloudwatch.Dimension) {
+	if cw.client == nil {
+		return
+	}
+
+	maxQueueSize := cw.MetricsMaxQueueSize
+	if maxQueueSize <= 0 {
+		maxQueueSize = cfg.DefaultMetricsMaxQueueSize
+	}
+
+	cw.mu.Lock()
+	cw.buffer = append(cw.buffer, &cloudwatch.MetricDatum{
+		MetricName: aws.String(name),
+		Unit:       aws.String(unit),
+		Value:      aws.Float64(value),
+		Dimensions: dimensions,
+	})
+	full := len(cw.buffer) >= maxQueueSize
+	cw.mu.Unlock()
+
+	if full {
+		cw.flush()
+	}
+}
+
+// flush sends every buffered datum to CloudWatch, chunked to
+// cloudWatchMaxDatumsPerCall per call. Errors are logged rather than
+// returned, since MonitoringService methods don't have a way to surface
+// them to the caller.
+func (cw *CloudWatchMonitoringService) flush() {
+	cw.mu.Lock()
+	pending := cw.buffer
+	cw.buffer = nil
+	cw.mu.Unlock()
+
+	for len(pending) > 0 {
+		n := cloudWatchMaxDatumsPerCall
+		if n > len(pending) {
+			n = len(pending)
+		}
+
+		_, err := cw.client.PutMetricData(&cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(cloudWatchNamespace),
+			MetricData: pending[:n],
+		})
+		if err != nil {
+			log.Errorf("Failed to put %d CloudWatch metric(s): %+v", n, err)
+		}
+
+		pending = pending[n:]
+	}
+}
+
+func shardDimension(shardID string) *cloudwatch.Dimension {
+	return &cloudwatch.Dimension{Name: aws.String("ShardID"), Value: aws.String(shardID)}
+}
+
+func workerDimension(workerID string) *cloudwatch.Dimension {
+	return &cloudwatch.Dimension{Name: aws.String("WorkerID"), Value: aws.String(workerID)}
+}
+
+func (cw *CloudWatchMonitoringService) IncrRecordsProcessed(shardID string, count int) {
+	cw.putMetric("RecordsProcessed", cloudwatch.StandardUnitCount, float64(count), shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) IncrRecordsGetRecordsCalled(shardID string, count int) {
+	cw.putMetric("GetRecordsCalled", cloudwatch.StandardUnitCount, float64(count), shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) IncrRecordsGetRecordsErrCount(shardID string, count int) {
+	cw.putMetric("GetRecordsErrCount", cloudwatch.StandardUnitCount, float64(count), shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) IncrRecordsGetRecordsTime(shardID string, time float64) {
+	cw.putMetric("GetRecordsTime", cloudwatch.StandardUnitMilliseconds, time, shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) IncrRecordsProcessedTime(shardID string, time float64) {
+	cw.putMetric("RecordsProcessedTime", cloudwatch.StandardUnitMilliseconds, time, shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) MillisBehindLatest(shardID string, millSeconds float64) {
+	cw.putMetric("MillisBehindLatest", cloudwatch.StandardUnitMilliseconds, millSeconds, shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) LeaseGained(shardID string) {
+	cw.putMetric("LeaseGained", cloudwatch.StandardUnitCount, 1, shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) LeaseLost(shardID string) {
+	cw.putMetric("LeaseLost", cloudwatch.StandardUnitCount, 1, shardDimension(shardID))
+}
+
+func (cw *CloudWatchMonitoringService) LeaseRenewed(shardID string) {
+	cw.putMetric("LeaseRenewed", cloudwatch.StandardUnitCount, 1, shardDimension(shardID))
+}
+
+// IncrConsumerMode records a record batch read off shardID, tagged by the
+// transport used to read it ("polling" or "fan-out"), so the two paths can
+// be compared on the same dashboard.
+func (cw *CloudWatchMonitoringService) IncrConsumerMode(shardID string, mode string, count int) {
+	cw.putMetric("ConsumerModeRecords", cloudwatch.StandardUnitCount, float64(count),
+		shardDimension(shardID), &cloudwatch.Dimension{Name: aws.String("Mode"), Value: aws.String(mode)})
+}
+
+func (cw *CloudWatchMonitoringService) LeaderElected(workerID string) {
+	cw.putMetric("LeaderElected", cloudwatch.StandardUnitCount, 1, workerDimension(workerID))
+}
+
+func (cw *CloudWatchMonitoringService) RebalanceTransfers(count int) {
+	cw.putMetric("RebalanceTransfers", cloudwatch.StandardUnitCount, float64(count))
+}
+
+func (cw *CloudWatchMonitoringService) WorkerLeaseCount(workerID string, count int) {
+	cw.putMetric("WorkerLeaseCount", cloudwatch.StandardUnitCount, float64(count), workerDimension(workerID))
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package metrics publishes worker health and throughput counters to
+// CloudWatch or Prometheus, depending on how MonitoringConfiguration is set up.
+package metrics
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// MonitoringService is implemented by each backend (CloudWatch,
+// Prometheus) that the worker can publish metrics to.
+type MonitoringService interface {
+	Init() error
+
+	IncrRecordsProcessed(shardID string, count int)
+	IncrRecordsGetRecordsCalled(shardID string, count int)
+	IncrRecordsGetRecordsErrCount(shardID string, count int)
+	IncrRecordsGetRecordsTime(shardID string, time float64)
+	IncrRecordsProcessedTime(shardID string, time float64)
+	MillisBehindLatest(shardID string, millSeconds float64)
+	LeaseGained(shardID string)
+	LeaseLost(shardID string)
+	LeaseRenewed(shardID string)
+
+	// IncrConsumerMode records a record batch read off shardID, tagged by
+	// the transport used to read it ("polling" or "fan-out"), so the two
+	// paths can be compared on the same dashboard.
+	IncrConsumerMode(shardID string, mode string, count int)
+
+	// LeaderElected records that workerID became the leader of the
+	// rebalancer; renewals by the current leader are not recorded. See
+	// leaderelection.LeaderElector.
+	LeaderElected(workerID string)
+
+	// RebalanceTransfers records how many leases the elected leader
+	// reassigned in a single rebalance pass.
+	RebalanceTransfers(count int)
+
+	// WorkerLeaseCount records how many shards workerID currently leases,
+	// so lease distribution across the fleet can be graphed.
+	WorkerLeaseCount(workerID string, count int)
+}
+
+// MonitoringConfiguration selects and configures the metrics backend the
+// worker publishes to. Leave MonitoringService empty to disable metrics.
+type MonitoringConfiguration struct {
+	MonitoringService string // "cloudwatch" or "prometheus"
+	Region            string
+
+	CloudWatch CloudWatchMonitoringService
+	Prometheus PrometheusMonitoringService
+
+	service MonitoringService
+}
+
+// CloudWatchMonitoringService configures the CloudWatch metrics backend.
+type CloudWatchMonitoringService struct {
+	Credentials             *credentials.Credentials
+	MetricsBufferTimeMillis int
+	MetricsMaxQueueSize     int
+
+	// Endpoint overrides the CloudWatch service endpoint, e.g. to point at
+	// LocalStack during integration testing.
+	Endpoint string
+
+	client cloudwatchiface.CloudWatchAPI
+
+	mu     sync.Mutex
+	buffer []*cloudwatch.MetricDatum
+}
+
+// PrometheusMonitoringService configures the Prometheus metrics backend.
+type PrometheusMonitoringService struct {
+	ListenAddress string
+}
+
+// GetMonitoringService lazily constructs and returns the configured
+// MonitoringService, or nil if none is configured.
+func (config *MonitoringConfiguration) GetMonitoringService() MonitoringService {
+	if config == nil {
+		return nil
+	}
+
+	if config.service != nil {
+		return config.service
+	}
+
+	switch config.MonitoringService {
+	case "cloudwatch":
+		config.service = &config.CloudWatch
+	case "prometheus":
+		config.service = &config.Prometheus
+	default:
+		return nil
+	}
+
+	return config.service
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	recordsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kcl_records_processed",
+		Help: "Number of records processed, by shard",
+	}, []string{"shard"})
+
+	millisBehindLatest = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kcl_millis_behind_latest",
+		Help: "Milliseconds the worker is behind the tip of the shard",
+	}, []string{"shard"})
+
+	consumerModeRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kcl_consumer_mode_records",
+		Help: "Records read per shard, broken down by consumer transport (polling vs fan-out)",
+	}, []string{"shard", "mode"})
+
+	leaderElected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kcl_leader_elected",
+		Help: "Number of times a worker became the rebalancer leader",
+	}, []string{"worker"})
+
+	rebalanceTransfers = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kcl_rebalance_transfers",
+		Help: "Number of leases reassigned by the elected leader, across all rebalance passes",
+	})
+
+	workerLeaseCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kcl_worker_lease_count",
+		Help: "Number of shards currently leased, by worker",
+	}, []string{"worker"})
+)
+
+// Init starts the Prometheus handler on ListenAddress. It satisfies MonitoringService.
+func (p *PrometheusMonitoringService) Init() error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		if err := http.ListenAndServe(p.ListenAddress, mux); err != nil {
+			log.Errorf("Prometheus metrics server stopped: %+v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (p *PrometheusMonitoringService) IncrRecordsProcessed(shardID string, count int) {
+	recordsProcessed.WithLabelValues(shardID).Add(float64(count))
+}
+
+func (p *PrometheusMonitoringService) IncrRecordsGetRecordsCalled(shardID string, count int) {}
+
+func (p *PrometheusMonitoringService) IncrRecordsGetRecordsErrCount(shardID string, count int) {}
+
+func (p *PrometheusMonitoringService) IncrRecordsGetRecordsTime(shardID string, time float64) {}
+
+func (p *PrometheusMonitoringService) IncrRecordsProcessedTime(shardID string, time float64) {}
+
+func (p *PrometheusMonitoringService) MillisBehindLatest(shardID string, millSeconds float64) {
+	millisBehindLatest.WithLabelValues(shardID).Set(millSeconds)
+}
+
+func (p *PrometheusMonitoringService) LeaseGained(shardID string) {}
+
+func (p *PrometheusMonitoringService) LeaseLost(shardID string) {}
+
+func (p *PrometheusMonitoringService) LeaseRenewed(shardID string) {}
+
+func (p *PrometheusMonitoringService) IncrConsumerMode(shardID string, mode string, count int) {
+	consumerModeRecords.WithLabelValues(shardID, mode).Add(float64(count))
+}
+
+func (p *PrometheusMonitoringService) LeaderElected(workerID string) {
+	leaderElected.WithLabelValues(workerID).Inc()
+}
+
+func (p *PrometheusMonitoringService) RebalanceTransfers(count int) {
+	rebalanceTransfers.Add(float64(count))
+}
+
+func (p *PrometheusMonitoringService) WorkerLeaseCount(workerID string, count int) {
+	workerLeaseCount.WithLabelValues(workerID).Set(float64(count))
+}
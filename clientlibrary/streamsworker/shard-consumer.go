@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package streamsworker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+	cfg "github.com/vmware/vmware-go-kcl/clientlibrary/config"
+	kc "github.com/vmware/vmware-go-kcl/clientlibrary/interfaces"
+)
+
+// minGetRecordsInterval throttles GetRecords calls to stay under the
+// DynamoDB Streams per-shard limit of 5 reads per second.
+const minGetRecordsInterval = 200 * time.Millisecond
+
+// ShardConsumer reads records from a single leased DynamoDB Streams shard
+// and hands them to an IRecordProcessor.
+type ShardConsumer struct {
+	worker       *Worker
+	shardID      string
+	closed       bool
+	processor    kc.IRecordProcessor
+	checkpointer kc.IRecordProcessorCheckpointer
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newShardConsumer(w *Worker, shardID string, closed bool) *ShardConsumer {
+	return &ShardConsumer{
+		worker:       w,
+		shardID:      shardID,
+		closed:       closed,
+		processor:    w.processorFactory.CreateProcessor(),
+		checkpointer: checkpoint.NewDynamoCheckpointer(w.dbClient, w.tableName, w.workerID, shardID),
+		stop:         make(chan struct{}),
+	}
+}
+
+// run initializes the record processor, then polls GetRecords until the
+// shard closes (for shards that were already closed when leased, this may
+// be after a single empty read) or the consumer is shut down.
+func (sc *ShardConsumer) run() {
+	lastCheckpoint, err := checkpoint.GetCheckpoint(sc.worker.dbClient, sc.worker.tableName, sc.shardID)
+	if err != nil {
+		log.Errorf("Shard %s: failed to read existing checkpoint: %+v", sc.shardID, err)
+	}
+
+	if lastCheckpoint == checkpoint.ShardEndCheckpoint {
+		// Already fully processed by a previous run; syncShards reads
+		// this same checkpoint to decide when children become leasable.
+		return
+	}
+
+	sc.processor.Initialize(&kc.InitializationInput{
+		ShardId: sc.shardID,
+		ExtendedSequenceNumber: &kc.ExtendedSequenceNumber{
+			SequenceNumber: aws.String(lastCheckpoint),
+		},
+	})
+
+	shardIterator, err := sc.initialIterator(lastCheckpoint)
+	if err != nil {
+		log.Errorf("Shard %s: failed to get shard iterator: %+v", sc.shardID, err)
+		return
+	}
+
+	var lastTick time.Time
+
+	for {
+		select {
+		case <-sc.stop:
+			return
+		default:
+		}
+
+		if shardIterator == nil {
+			sc.shutdown(kc.TERMINATE)
+			return
+		}
+
+		if elapsed := time.Since(lastTick); elapsed < minGetRecordsInterval {
+			time.Sleep(minGetRecordsInterval - elapsed)
+		}
+		lastTick = time.Now()
+
+		out, err := sc.worker.streamsClient.GetRecords(&dynamodbstreams.GetRecordsInput{
+			ShardIterator: shardIterator,
+			Limit:         aws.Int64(1000),
+		})
+		if err != nil {
+			log.Errorf("Shard %s: GetRecords failed: %+v", sc.shardID, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if len(out.Records) > 0 {
+			sc.deliver(out.Records)
+		}
+
+		shardIterator = out.NextShardIterator
+
+		if len(out.Records) == 0 && shardIterator != nil && !sc.closed {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// initialIterator resumes from the last checkpoint if there is one,
+// otherwise starts from the configured InitialPositionInStream.
+func (sc *ShardConsumer) initialIterator(lastCheckpoint string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn: aws.String(sc.worker.streamArn),
+		ShardId:   aws.String(sc.shardID),
+	}
+
+	if lastCheckpoint != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(lastCheckpoint)
+	} else if sc.worker.kclConfig.InitialPositionInStream == cfg.TRIM_HORIZON {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+	} else {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+
+	out, err := sc.worker.streamsClient.GetShardIterator(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.ShardIterator, nil
+}
+
+func (sc *ShardConsumer) deliver(records []*dynamodbstreams.Record) {
+	if sc.worker.mService != nil {
+		sc.worker.mService.IncrRecordsProcessed(sc.shardID, len(records))
+	}
+
+	sc.processor.ProcessRecords(&kc.ProcessRecordsInput{
+		Records:      records,
+		Checkpointer: sc.checkpointer,
+	})
+}
+
+// shutdown may be called concurrently - e.g. the sync loop reassigning this
+// shard's lease at the same time the consumer's own goroutine is shutting
+// down after reaching the end of a closed shard - so closing sc.stop must
+// be idempotent.
+func (sc *ShardConsumer) shutdown(reason kc.ShutdownReason) {
+	sc.stopOnce.Do(func() {
+		close(sc.stop)
+	})
+
+	sc.processor.Shutdown(&kc.ShutdownInput{
+		ShutdownReason: reason,
+		Checkpointer:   sc.checkpointer,
+	})
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package streamsworker consumes a DynamoDB Streams-enabled table
+// directly through dynamodbstreamsiface.DynamoDBStreamsAPI, the same way
+// package worker consumes a Kinesis stream. It exists so that users whose
+// "stream" is really a DynamoDB table no longer need to run the Java
+// Kinesis Adapter (or a homegrown bridge) in front of this library.
+package streamsworker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+	cfg "github.com/vmware/vmware-go-kcl/clientlibrary/config"
+	kc "github.com/vmware/vmware-go-kcl/clientlibrary/interfaces"
+	"github.com/vmware/vmware-go-kcl/clientlibrary/metrics"
+)
+
+// Worker discovers the shards of a DynamoDB table's stream, leases and
+// checkpoints them through the DynamoDB lease table, and drives one
+// ShardConsumer per leased shard through the IRecordProcessor it was
+// constructed with. Unlike package worker, records are delivered as
+// native dynamodbstreams.Record values with no Kinesis Adapter in between.
+type Worker struct {
+	regionName string
+	tableName  string
+	workerID   string
+
+	streamArn string
+
+	processorFactory kc.IRecordProcessorFactory
+	kclConfig        *cfg.KinesisClientLibConfiguration
+	metricsConfig    *metrics.MonitoringConfiguration
+	mService         metrics.MonitoringService
+
+	streamsClient dynamodbstreamsiface.DynamoDBStreamsAPI
+	dbClient      dynamodbiface.DynamoDBAPI
+
+	lock      sync.Mutex
+	consumers map[string]*ShardConsumer
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewWorker constructs a streams Worker for the given record processor
+// factory and configuration. kclConfig.StreamName names the DynamoDB
+// table whose stream is consumed. Callers must still call Start.
+func NewWorker(factory kc.IRecordProcessorFactory, kclConfig *cfg.KinesisClientLibConfiguration, metricsConfig *metrics.MonitoringConfiguration) *Worker {
+	return &Worker{
+		regionName: kclConfig.RegionName,
+		tableName:  kclConfig.StreamName,
+		workerID:   kclConfig.WorkerID,
+
+		processorFactory: factory,
+		kclConfig:        kclConfig,
+		metricsConfig:    metricsConfig,
+
+		consumers: make(map[string]*ShardConsumer),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start resolves the table's stream ARN, builds the AWS service clients,
+// initializes metrics and begins the shard sync loop.
+func (w *Worker) Start() error {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(w.regionName),
+		Credentials: w.kclConfig.DynamoDBCredentials,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %+v", err)
+	}
+
+	dbConfig := &aws.Config{Credentials: w.kclConfig.DynamoDBCredentials}
+	if w.kclConfig.DynamoDBEndpoint != "" {
+		dbConfig.Endpoint = aws.String(w.kclConfig.DynamoDBEndpoint)
+	}
+
+	w.dbClient = dynamodb.New(sess, dbConfig)
+	w.streamsClient = dynamodbstreams.New(sess, dbConfig)
+
+	table, err := w.dbClient.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(w.tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table %s: %+v", w.tableName, err)
+	}
+	if table.Table.LatestStreamArn == nil {
+		return fmt.Errorf("table %s does not have a DynamoDB Stream enabled", w.tableName)
+	}
+	w.streamArn = aws.StringValue(table.Table.LatestStreamArn)
+
+	if w.metricsConfig != nil {
+		if w.metricsConfig.MonitoringService == "cloudwatch" && w.kclConfig.CloudWatchEndpoint != "" {
+			w.metricsConfig.CloudWatch.Endpoint = w.kclConfig.CloudWatchEndpoint
+		}
+		w.mService = w.metricsConfig.GetMonitoringService()
+		if w.mService != nil {
+			if err := w.mService.Init(); err != nil {
+				log.Errorf("Failed to initialize monitoring service: %+v", err)
+			}
+		}
+	}
+
+	w.done.Add(1)
+	go w.shardSyncLoop()
+
+	return nil
+}
+
+// Shutdown stops the shard sync loop and every active shard consumer,
+// blocking until they have all exited.
+func (w *Worker) Shutdown() {
+	close(w.stop)
+
+	w.lock.Lock()
+	for _, c := range w.consumers {
+		c.shutdown(kc.ZOMBIE)
+	}
+	w.lock.Unlock()
+
+	w.done.Wait()
+}
+
+func (w *Worker) shardSyncLoop() {
+	defer w.done.Done()
+
+	ticker := time.NewTicker(time.Duration(w.kclConfig.ShardSyncIntervalMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	w.syncShards()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.syncShards()
+		}
+	}
+}
+
+// syncShards walks the stream's shard graph and starts a ShardConsumer
+// for every leasable shard this worker isn't already consuming. A shard
+// is leasable once its parent (if any) has been fully processed, so
+// children are never leased - and their records never delivered - before
+// their parent closes.
+func (w *Worker) syncShards() {
+	shards, err := w.describeAllShards()
+	if err != nil {
+		log.Errorf("Failed to describe stream %s: %+v", w.streamArn, err)
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// Re-affirm leases this worker already holds, and stop consuming any
+	// shard whose lease now belongs to another worker.
+	for shardID, consumer := range w.consumers {
+		acquired, err := checkpoint.TryAcquireLease(w.dbClient, w.tableName, shardID, w.workerID)
+		if err != nil {
+			log.Errorf("Failed to renew lease for shard %s: %+v", shardID, err)
+			continue
+		}
+		if !acquired {
+			consumer.shutdown(kc.ZOMBIE)
+			delete(w.consumers, shardID)
+			if w.mService != nil {
+				w.mService.LeaseLost(shardID)
+			}
+		}
+	}
+
+	for _, shard := range shards {
+		shardID := aws.StringValue(shard.ShardId)
+		if _, exists := w.consumers[shardID]; exists {
+			continue
+		}
+
+		if len(w.consumers) >= w.kclConfig.MaxLeasesForWorker {
+			break
+		}
+
+		parentID := aws.StringValue(shard.ParentShardId)
+		if parentID != "" {
+			parentCheckpoint, err := checkpoint.GetCheckpoint(w.dbClient, w.tableName, parentID)
+			if err != nil {
+				log.Errorf("Failed to read parent shard %s checkpoint: %+v", parentID, err)
+				continue
+			}
+			if parentCheckpoint != checkpoint.ShardEndCheckpoint {
+				// Parent is still open (or still being processed by this or
+				// another worker, possibly one that never shares this
+				// worker's in-memory state); its children must wait. This
+				// reads the shared lease table rather than any local
+				// cache, since the worker that finishes a parent is often
+				// not the one eligible to lease its children.
+				continue
+			}
+		}
+
+		acquired, err := checkpoint.TryAcquireLease(w.dbClient, w.tableName, shardID, w.workerID)
+		if err != nil {
+			log.Errorf("Failed to acquire lease for shard %s: %+v", shardID, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		closed := shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil
+
+		consumer := newShardConsumer(w, shardID, closed)
+		w.consumers[shardID] = consumer
+
+		if w.mService != nil {
+			w.mService.LeaseGained(shardID)
+		}
+
+		w.done.Add(1)
+		go func() {
+			defer w.done.Done()
+			consumer.run()
+		}()
+	}
+}
+
+// describeAllShards pages through DescribeStream to return the full
+// parent/child shard graph for the stream.
+func (w *Worker) describeAllShards() ([]*dynamodbstreams.Shard, error) {
+	var shards []*dynamodbstreams.Shard
+	var lastShardID *string
+
+	for {
+		out, err := w.streamsClient.DescribeStream(&dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(w.streamArn),
+			ExclusiveStartShardId: lastShardID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		shards = append(shards, out.StreamDescription.Shards...)
+
+		lastShardID = out.StreamDescription.LastEvaluatedShardId
+		if lastShardID == nil {
+			break
+		}
+	}
+
+	return shards, nil
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package worker
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	log "github.com/sirupsen/logrus"
+
+	kc "github.com/vmware/vmware-go-kcl/clientlibrary/interfaces"
+)
+
+// toInterfaceRecords decodes the DynamoDB Streams record the Kinesis
+// Adapter embedded as JSON in each Kinesis record's Data blob. Records
+// that fail to decode are dropped rather than delivered half-formed.
+func toInterfaceRecords(records []*kinesis.Record) []*kc.Record {
+	out := make([]*kc.Record, 0, len(records))
+
+	for _, r := range records {
+		var rec kc.Record
+		if err := json.Unmarshal(r.Data, &rec); err != nil {
+			log.Errorf("Failed to decode DynamoDB Streams record from Kinesis Adapter payload: %+v", err)
+			continue
+		}
+		out = append(out, &rec)
+	}
+
+	return out
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package worker
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+	"github.com/vmware/vmware-go-kcl/clientlibrary/leaderelection"
+)
+
+// leaderShardID mirrors leaderelection's own lock row ID so the
+// rebalancer never treats the leader lock itself as a shard to assign.
+const leaderShardID = "__leader__"
+
+// renewalInterval is how often a leader renews (and a follower attempts
+// to acquire) the leader lock, jittered to avoid every worker in the
+// fleet campaigning in lockstep.
+func renewalInterval(leaseDuration time.Duration) time.Duration {
+	base := leaseDuration / 3
+	jitter := time.Duration(rand.Int63n(int64(base) / 2))
+	return base + jitter
+}
+
+// runLeaderElection campaigns for leadership every renewal interval and,
+// while leader, periodically rebalances lease assignments across the
+// fleet. It exits when w.stop is closed.
+func (w *Worker) runLeaderElection() {
+	defer w.done.Done()
+
+	elector := w.kclConfig.LeaderElector
+	if elector == nil {
+		elector = leaderelection.NewDynamoDBLeaderElector(w.dbClient, w.kclConfig.StreamName)
+	}
+
+	leaseDuration := leaderelection.DefaultLeaseDuration
+	if dle, ok := elector.(*leaderelection.DynamoDBLeaderElector); ok {
+		leaseDuration = dle.LeaseDuration
+	}
+
+	isLeader := false
+
+	for {
+		select {
+		case <-w.stop:
+			if isLeader {
+				_ = elector.StepDown(w.workerID)
+			}
+			return
+		case <-time.After(renewalInterval(leaseDuration)):
+		}
+
+		elected, err := elector.Campaign(w.workerID)
+		if err != nil {
+			log.Errorf("Leader election campaign failed: %+v", err)
+			if isLeader {
+				_ = elector.StepDown(w.workerID)
+				isLeader = false
+			}
+			continue
+		}
+
+		if elected && !isLeader && w.mService != nil {
+			w.mService.LeaderElected(w.workerID)
+		}
+		isLeader = elected
+
+		if isLeader {
+			w.rebalance()
+		}
+	}
+}
+
+// rebalance reads every lease, computes a target assignment that
+// equalizes leases per worker within MaxLeasesForWorker, and writes
+// DesiredOwner hints for any lease that needs to move. It never reassigns
+// a lease directly - the current owner keeps it until the named worker
+// calls checkpoint.TryAcquireLease, so a rebalance can never yank a shard
+// out from under a processor mid-batch.
+func (w *Worker) rebalance() {
+	leases, err := checkpoint.ListLeases(w.dbClient, w.kclConfig.StreamName)
+	if err != nil {
+		log.Errorf("Rebalance: failed to list leases: %+v", err)
+		return
+	}
+
+	heartbeats, err := checkpoint.ListWorkerHeartbeats(w.dbClient, w.kclConfig.StreamName)
+	if err != nil {
+		log.Errorf("Rebalance: failed to list worker heartbeats: %+v", err)
+	}
+
+	// A worker counts as live only if it heartbeated recently; one that
+	// stopped renewing is presumed gone rather than idle. FailoverTimeMillis
+	// is the staleness bound.
+	staleAfter := time.Duration(w.kclConfig.FailoverTimeMillis) * time.Millisecond
+	liveWorkers := make([]string, 0, len(heartbeats))
+	for _, hb := range heartbeats {
+		if time.Since(hb.LastSeen) <= staleAfter {
+			liveWorkers = append(liveWorkers, hb.WorkerID)
+		}
+	}
+
+	transfers := computeRebalance(leases, liveWorkers, w.kclConfig.MaxLeasesForWorker)
+
+	for _, t := range transfers {
+		if err := checkpoint.SetDesiredOwner(w.dbClient, w.kclConfig.StreamName, t.shardID, t.toOwner); err != nil {
+			log.Errorf("Rebalance: failed to set desired owner for shard %s: %+v", t.shardID, err)
+			continue
+		}
+	}
+
+	if w.mService != nil {
+		w.mService.RebalanceTransfers(len(transfers))
+
+		counts := make(map[string]int)
+		for _, lease := range leases {
+			if lease.ShardID == leaderShardID || lease.LeaseOwner == "" {
+				continue
+			}
+			counts[lease.LeaseOwner]++
+		}
+		for owner, count := range counts {
+			w.mService.WorkerLeaseCount(owner, count)
+		}
+	}
+}
+
+// transfer is a single rebalance decision: shardID should move from its
+// current owner to toOwner.
+type transfer struct {
+	shardID string
+	toOwner string
+}
+
+// computeRebalance equalizes leases/worker across every worker currently
+// holding at least one lease plus every worker named in liveWorkers,
+// honoring maxLeasesForWorker as a hard cap on any destination. Including
+// liveWorkers lets a worker that holds zero leases - newly joined, or
+// just recovered from a crash - still be picked as a rebalance recipient;
+// otherwise it could never appear as a destination for a DesiredOwner
+// hint. It is a pure function so it can be unit tested without a
+// DynamoDB table.
+func computeRebalance(leases []checkpoint.Lease, liveWorkers []string, maxLeasesForWorker int) []transfer {
+	byOwner := make(map[string][]string) // owner -> shard IDs
+	for _, lease := range leases {
+		if lease.ShardID == leaderShardID || lease.LeaseOwner == "" || lease.DesiredOwner != "" {
+			// Unowned shards aren't being rebalanced (they'll simply be
+			// leased next sync), and shards with a pending hint are left
+			// alone until that transfer completes.
+			continue
+		}
+		byOwner[lease.LeaseOwner] = append(byOwner[lease.LeaseOwner], lease.ShardID)
+	}
+
+	for _, workerID := range liveWorkers {
+		if _, ok := byOwner[workerID]; !ok {
+			byOwner[workerID] = nil
+		}
+	}
+
+	if len(byOwner) < 2 {
+		return nil
+	}
+
+	owners := make([]string, 0, len(byOwner))
+	total := 0
+	for owner, shards := range byOwner {
+		owners = append(owners, owner)
+		total += len(shards)
+	}
+	sort.Strings(owners)
+
+	target := total / len(owners)
+	if target > maxLeasesForWorker {
+		target = maxLeasesForWorker
+	}
+
+	var transfers []transfer
+	donorIdx := 0
+
+	// Sort owners by load descending so the most over-loaded donors move
+	// shards to the least-loaded recipients first.
+	sort.Slice(owners, func(i, j int) bool { return len(byOwner[owners[i]]) > len(byOwner[owners[j]]) })
+
+	for i := len(owners) - 1; i >= 0; i-- {
+		recipient := owners[i]
+		for len(byOwner[recipient]) < target {
+			// Find a donor with shards to spare.
+			for donorIdx < i && len(byOwner[owners[donorIdx]]) <= target {
+				donorIdx++
+			}
+			if donorIdx >= i {
+				break
+			}
+
+			donor := owners[donorIdx]
+			donorShards := byOwner[donor]
+			shardID := donorShards[len(donorShards)-1]
+			byOwner[donor] = donorShards[:len(donorShards)-1]
+			byOwner[recipient] = append(byOwner[recipient], shardID)
+
+			transfers = append(transfers, transfer{shardID: shardID, toOwner: recipient})
+		}
+	}
+
+	return transfers
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+)
+
+func TestComputeRebalanceEqualizesLoad(t *testing.T) {
+	leases := []checkpoint.Lease{
+		{ShardID: "shard-1", LeaseOwner: "worker-a"},
+		{ShardID: "shard-2", LeaseOwner: "worker-a"},
+		{ShardID: "shard-3", LeaseOwner: "worker-a"},
+		{ShardID: "shard-4", LeaseOwner: "worker-a"},
+		{ShardID: "shard-5", LeaseOwner: "worker-b"},
+	}
+
+	transfers := computeRebalance(leases, nil, 10)
+
+	counts := map[string]int{"worker-a": 4, "worker-b": 1}
+	for _, tr := range transfers {
+		counts[tr.toOwner]++
+		for owner := range counts {
+			if owner != tr.toOwner {
+				counts[owner]--
+			}
+		}
+	}
+
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, "worker-b", transfers[0].toOwner)
+}
+
+func TestComputeRebalanceSingleWorkerIsNoOp(t *testing.T) {
+	leases := []checkpoint.Lease{
+		{ShardID: "shard-1", LeaseOwner: "worker-a"},
+		{ShardID: "shard-2", LeaseOwner: "worker-a"},
+	}
+
+	assert.Empty(t, computeRebalance(leases, nil, 10))
+}
+
+func TestComputeRebalanceIgnoresShardsWithPendingHint(t *testing.T) {
+	leases := []checkpoint.Lease{
+		{ShardID: "shard-1", LeaseOwner: "worker-a"},
+		{ShardID: "shard-2", LeaseOwner: "worker-a", DesiredOwner: "worker-b"},
+		{ShardID: "shard-3", LeaseOwner: "worker-b"},
+	}
+
+	assert.Empty(t, computeRebalance(leases, nil, 10))
+}
+
+func TestComputeRebalanceUsesIdleLiveWorkerAsRecipient(t *testing.T) {
+	leases := []checkpoint.Lease{
+		{ShardID: "shard-1", LeaseOwner: "worker-a"},
+		{ShardID: "shard-2", LeaseOwner: "worker-a"},
+		{ShardID: "shard-3", LeaseOwner: "worker-a"},
+		{ShardID: "shard-4", LeaseOwner: "worker-a"},
+	}
+
+	// worker-b holds no leases yet, but it heartbeated so it must still
+	// receive shards from worker-a.
+	transfers := computeRebalance(leases, []string{"worker-a", "worker-b"}, 10)
+
+	assert.Len(t, transfers, 2)
+	for _, tr := range transfers {
+		assert.Equal(t, "worker-b", tr.toOwner)
+	}
+}
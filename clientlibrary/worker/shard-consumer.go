@@ -0,0 +1,316 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+	cfg "github.com/vmware/vmware-go-kcl/clientlibrary/config"
+	kc "github.com/vmware/vmware-go-kcl/clientlibrary/interfaces"
+)
+
+const (
+	// consumerModePolling tags metrics/logs produced by the GetRecords polling path.
+	consumerModePolling = "polling"
+
+	// consumerModeFanOut tags metrics/logs produced by the SubscribeToShard push path.
+	consumerModeFanOut = "fan-out"
+
+	// subscribeToShardLifetime is how long the SDK keeps a single
+	// SubscribeToShard HTTP/2 stream open before ending it; the consumer
+	// must resubscribe to keep receiving records.
+	subscribeToShardLifetime = 5 * time.Minute
+)
+
+// ShardConsumer reads records from a single leased shard and hands them
+// to an IRecordProcessor, via either polling GetRecords calls or a pushed
+// SubscribeToShard event stream.
+type ShardConsumer struct {
+	worker       *Worker
+	shardID      string
+	processor    kc.IRecordProcessor
+	checkpointer kc.IRecordProcessorCheckpointer
+
+	consumerARN string
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+func newShardConsumer(w *Worker, shardID string) *ShardConsumer {
+	return &ShardConsumer{
+		worker:       w,
+		shardID:      shardID,
+		processor:    w.processorFactory.CreateProcessor(),
+		checkpointer: checkpoint.NewDynamoCheckpointer(w.dbClient, w.kclConfig.StreamName, w.workerID, shardID),
+		stop:         make(chan struct{}),
+	}
+}
+
+// run initializes the record processor and consumes the shard until it is
+// closed or the consumer is shut down, preferring enhanced fan-out when
+// configured and falling back to polling when fan-out isn't available.
+func (sc *ShardConsumer) run() {
+	lastCheckpoint, err := checkpoint.GetCheckpoint(sc.worker.dbClient, sc.worker.streamName, sc.shardID)
+	if err != nil {
+		log.Errorf("Shard %s: failed to read existing checkpoint: %+v", sc.shardID, err)
+	}
+
+	sc.processor.Initialize(&kc.InitializationInput{
+		ShardId: sc.shardID,
+		ExtendedSequenceNumber: &kc.ExtendedSequenceNumber{
+			SequenceNumber: aws.String(lastCheckpoint),
+		},
+	})
+
+	if sc.worker.kclConfig.EnableEnhancedFanOut {
+		arn, err := sc.resolveConsumerARN()
+		if err != nil {
+			log.Warnf("Shard %s: enhanced fan-out unavailable (%+v), falling back to polling", sc.shardID, err)
+		} else {
+			sc.consumerARN = arn
+			sc.runFanOut(lastCheckpoint)
+			return
+		}
+	}
+
+	sc.runPolling(lastCheckpoint)
+}
+
+// resolveConsumerARN registers (or looks up an already-registered) stream
+// consumer for enhanced fan-out. It returns an error - rather than failing
+// Start() - when the ARN can't be resolved, e.g. because the account has
+// already registered the maximum of 5 consumers on the stream.
+func (sc *ShardConsumer) resolveConsumerARN() (string, error) {
+	streamOut, err := sc.worker.kinesisClient.DescribeStreamSummary(&kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(sc.worker.streamName),
+	})
+	if err != nil {
+		return "", err
+	}
+	streamARN := streamOut.StreamDescriptionSummary.StreamARN
+
+	describeOut, err := sc.worker.kinesisClient.DescribeStreamConsumer(&kinesis.DescribeStreamConsumerInput{
+		StreamARN:    streamARN,
+		ConsumerName: aws.String(sc.worker.kclConfig.EnhancedFanOutConsumerName),
+	})
+	if err == nil {
+		return aws.StringValue(describeOut.ConsumerDescription.ConsumerARN), nil
+	}
+
+	registerOut, err := sc.worker.kinesisClient.RegisterStreamConsumer(&kinesis.RegisterStreamConsumerInput{
+		StreamARN:    streamARN,
+		ConsumerName: aws.String(sc.worker.kclConfig.EnhancedFanOutConsumerName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(registerOut.Consumer.ConsumerARN), nil
+}
+
+// runPolling drives the shard via repeated GetRecords calls, the original
+// consumption path and the fallback for when fan-out isn't available.
+func (sc *ShardConsumer) runPolling(lastCheckpoint string) {
+	shardIterator, err := sc.initialIterator(lastCheckpoint)
+	if err != nil {
+		log.Errorf("Shard %s: failed to get shard iterator: %+v", sc.shardID, err)
+		return
+	}
+
+	for {
+		select {
+		case <-sc.stop:
+			return
+		default:
+		}
+
+		if shardIterator == nil {
+			// The shard has been fully read and closed (split/merge).
+			sc.shutdown(kc.TERMINATE)
+			return
+		}
+
+		out, err := sc.worker.kinesisClient.GetRecords(&kinesis.GetRecordsInput{
+			ShardIterator: shardIterator,
+			Limit:         aws.Int64(int64(sc.worker.kclConfig.MaxRecords)),
+		})
+		if err != nil {
+			log.Errorf("Shard %s: GetRecords failed: %+v", sc.shardID, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		sc.deliver(toInterfaceRecords(out.Records), aws.Int64Value(out.MillisBehindLatest), consumerModePolling)
+		shardIterator = out.NextShardIterator
+
+		if len(out.Records) == 0 {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// initialIterator resumes from the last checkpoint if there is one,
+// otherwise starts from the configured InitialPositionInStream.
+func (sc *ShardConsumer) initialIterator(lastCheckpoint string) (*string, error) {
+	input := &kinesis.GetShardIteratorInput{
+		StreamName: aws.String(sc.worker.streamName),
+		ShardId:    aws.String(sc.shardID),
+	}
+
+	if lastCheckpoint != "" {
+		input.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber)
+		input.StartingSequenceNumber = aws.String(lastCheckpoint)
+	} else if sc.worker.kclConfig.InitialPositionInStream == cfg.TRIM_HORIZON {
+		input.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeTrimHorizon)
+	} else {
+		input.ShardIteratorType = aws.String(kinesis.ShardIteratorTypeLatest)
+	}
+
+	out, err := sc.worker.kinesisClient.GetShardIterator(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.ShardIterator, nil
+}
+
+// initialStartingPosition mirrors initialIterator's resume logic for the
+// first SubscribeToShard call of a fan-out session.
+func (sc *ShardConsumer) initialStartingPosition(lastCheckpoint string) *kinesis.StartingPosition {
+	if lastCheckpoint != "" {
+		return &kinesis.StartingPosition{
+			Type:           aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber),
+			SequenceNumber: aws.String(lastCheckpoint),
+		}
+	}
+
+	if sc.worker.kclConfig.InitialPositionInStream == cfg.TRIM_HORIZON {
+		return &kinesis.StartingPosition{Type: aws.String(kinesis.ShardIteratorTypeTrimHorizon)}
+	}
+
+	return &kinesis.StartingPosition{Type: aws.String(kinesis.ShardIteratorTypeLatest)}
+}
+
+// runFanOut subscribes to the shard over the push-based event stream and
+// resubscribes every subscribeToShardLifetime, as required by the SDK.
+func (sc *ShardConsumer) runFanOut(lastCheckpoint string) {
+	startingPosition := sc.initialStartingPosition(lastCheckpoint)
+
+	for {
+		select {
+		case <-sc.stop:
+			return
+		default:
+		}
+
+		out, err := sc.worker.kinesisClient.SubscribeToShard(&kinesis.SubscribeToShardInput{
+			ConsumerARN:      aws.String(sc.consumerARN),
+			ShardId:          aws.String(sc.shardID),
+			StartingPosition: startingPosition,
+		})
+		if err != nil {
+			log.Errorf("Shard %s: SubscribeToShard failed: %+v", sc.shardID, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		lastSeen := sc.consumeEventStream(out.EventStream)
+		if lastSeen != nil {
+			startingPosition = &kinesis.StartingPosition{
+				Type:           aws.String(kinesis.ShardIteratorTypeAtSequenceNumber),
+				SequenceNumber: lastSeen,
+			}
+		}
+
+		select {
+		case <-sc.stop:
+			return
+		default:
+		}
+	}
+}
+
+// consumeEventStream reads SubscribeToShardEvent frames until the SDK
+// ends the stream (at subscribeToShardLifetime) or an error occurs,
+// returning the continuation sequence number to resubscribe from. When the
+// shard turns out to be closed it shuts the consumer down with TERMINATE
+// and returns nil.
+func (sc *ShardConsumer) consumeEventStream(stream *kinesis.SubscribeToShardEventStream) *string {
+	defer stream.Close()
+
+	var continuationSequenceNumber *string
+
+	for event := range stream.Events() {
+		switch e := event.(type) {
+		case *kinesis.SubscribeToShardEvent:
+			sc.deliver(toInterfaceRecords(e.Records), aws.Int64Value(e.MillisBehindLatest), consumerModeFanOut)
+			continuationSequenceNumber = e.ContinuationSequenceNumber
+			if continuationSequenceNumber == nil {
+				// A nil continuation sequence number marks the end of a
+				// closed shard (split/merge).
+				sc.shutdown(kc.TERMINATE)
+				return nil
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		log.Warnf("Shard %s: fan-out event stream ended: %+v", sc.shardID, err)
+	}
+
+	return continuationSequenceNumber
+}
+
+// deliver hands a batch of records to the record processor and reports
+// per-mode metrics so fan-out and polling reads can be compared.
+func (sc *ShardConsumer) deliver(records []*kc.Record, millisBehindLatest int64, mode string) {
+	if sc.worker.mService != nil {
+		sc.worker.mService.IncrConsumerMode(sc.shardID, mode, len(records))
+		sc.worker.mService.IncrRecordsProcessed(sc.shardID, len(records))
+		sc.worker.mService.MillisBehindLatest(sc.shardID, float64(millisBehindLatest))
+	}
+
+	sc.processor.ProcessRecords(&kc.ProcessRecordsInput{
+		Records:            records,
+		MillisBehindLatest: millisBehindLatest,
+		Checkpointer:       sc.checkpointer,
+	})
+}
+
+// shutdown may be called concurrently - e.g. the sync loop reassigning this
+// shard's lease at the same time the consumer's own goroutine is shutting
+// down after reaching the end of a closed shard - so closing sc.stop must
+// be idempotent.
+func (sc *ShardConsumer) shutdown(reason kc.ShutdownReason) {
+	sc.stopOnce.Do(func() {
+		close(sc.stop)
+	})
+
+	sc.processor.Shutdown(&kc.ShutdownInput{
+		ShutdownReason: reason,
+		Checkpointer:   sc.checkpointer,
+	})
+}
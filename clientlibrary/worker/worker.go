@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2018 VMware, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this software and
+ * associated documentation files (the "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial
+ * portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+ * NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+ * WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+// Package worker drives shard discovery, lease acquisition and record
+// processing against a single Kinesis-compatible stream.
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vmware/vmware-go-kcl/clientlibrary/checkpoint"
+	cfg "github.com/vmware/vmware-go-kcl/clientlibrary/config"
+	kc "github.com/vmware/vmware-go-kcl/clientlibrary/interfaces"
+	"github.com/vmware/vmware-go-kcl/clientlibrary/metrics"
+)
+
+// Worker discovers the shards of a single stream, leases and renews them
+// through the DynamoDB lease table, and drives one ShardConsumer per
+// leased shard through the IRecordProcessor it was constructed with.
+type Worker struct {
+	regionName string
+	streamName string
+	workerID   string
+
+	processorFactory kc.IRecordProcessorFactory
+	kclConfig        *cfg.KinesisClientLibConfiguration
+	metricsConfig    *metrics.MonitoringConfiguration
+	mService         metrics.MonitoringService
+
+	kinesisClient kinesisiface.KinesisAPI
+	dbClient      dynamodbiface.DynamoDBAPI
+
+	consumersLock sync.Mutex
+	consumers     map[string]*ShardConsumer
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewWorker constructs a Worker for the given record processor factory and
+// configuration. Callers must still call Start to begin consuming.
+func NewWorker(factory kc.IRecordProcessorFactory, kclConfig *cfg.KinesisClientLibConfiguration, metricsConfig *metrics.MonitoringConfiguration) *Worker {
+	w := &Worker{
+		regionName: kclConfig.RegionName,
+		streamName: kclConfig.StreamName,
+		workerID:   kclConfig.WorkerID,
+
+		processorFactory: factory,
+		kclConfig:        kclConfig,
+		metricsConfig:    metricsConfig,
+
+		consumers: make(map[string]*ShardConsumer),
+		stop:      make(chan struct{}),
+	}
+
+	return w
+}
+
+// Start builds the AWS service clients, initializes metrics and begins the
+// shard sync loop that discovers and leases shards.
+func (w *Worker) Start() error {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(w.regionName),
+		Credentials: w.kclConfig.KinesisCredentials,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %+v", err)
+	}
+
+	w.kinesisClient = kinesis.New(sess, w.serviceConfig(w.kclConfig.KinesisCredentials, w.kclConfig.KinesisEndpoint))
+	w.dbClient = dynamodb.New(sess, w.serviceConfig(w.kclConfig.DynamoDBCredentials, w.kclConfig.DynamoDBEndpoint))
+
+	if w.metricsConfig != nil {
+		if w.metricsConfig.MonitoringService == "cloudwatch" && w.kclConfig.CloudWatchEndpoint != "" {
+			w.metricsConfig.CloudWatch.Endpoint = w.kclConfig.CloudWatchEndpoint
+		}
+		w.mService = w.metricsConfig.GetMonitoringService()
+		if w.mService != nil {
+			if err := w.mService.Init(); err != nil {
+				log.Errorf("Failed to initialize monitoring service: %+v", err)
+			}
+		}
+	}
+
+	w.done.Add(1)
+	go w.shardSyncLoop()
+
+	if w.kclConfig.EnableLeaderElection {
+		w.done.Add(1)
+		go w.runLeaderElection()
+	}
+
+	return nil
+}
+
+// serviceConfig builds the per-client aws.Config, applying the
+// LocalStack-style endpoint override (and matching path-style addressing)
+// configured on kclConfig, if any.
+func (w *Worker) serviceConfig(creds *credentials.Credentials, endpoint string) *aws.Config {
+	config := &aws.Config{Credentials: creds}
+
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		config.S3ForcePathStyle = aws.Bool(w.kclConfig.S3ForcePathStyle)
+	}
+
+	return config
+}
+
+// Shutdown stops the shard sync loop and every active shard consumer,
+// blocking until they have all exited.
+func (w *Worker) Shutdown() {
+	close(w.stop)
+
+	w.consumersLock.Lock()
+	for _, c := range w.consumers {
+		c.shutdown(kc.ZOMBIE)
+	}
+	w.consumersLock.Unlock()
+
+	w.done.Wait()
+}
+
+// shardSyncLoop periodically discovers shards and starts a ShardConsumer
+// for any this worker is leasing that it isn't already consuming.
+func (w *Worker) shardSyncLoop() {
+	defer w.done.Done()
+
+	ticker := time.NewTicker(time.Duration(w.kclConfig.ShardSyncIntervalMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	w.syncShards()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.syncShards()
+		}
+	}
+}
+
+func (w *Worker) syncShards() {
+	// Record that this worker is alive even if it ends up leasing nothing
+	// this pass, so the leader-elected rebalancer can still hand it shards.
+	if err := checkpoint.HeartbeatWorker(w.dbClient, w.streamName, w.workerID); err != nil {
+		log.Errorf("Failed to record worker heartbeat: %+v", err)
+	}
+
+	shardsOut, err := w.kinesisClient.ListShards(&kinesis.ListShardsInput{
+		StreamName: aws.String(w.streamName),
+	})
+	if err != nil {
+		log.Errorf("Failed to list shards for stream %s: %+v", w.streamName, err)
+		return
+	}
+
+	w.consumersLock.Lock()
+	defer w.consumersLock.Unlock()
+
+	// Re-affirm leases this worker already holds. If the leader-elected
+	// rebalancer has handed a lease to another worker via a DesiredOwner
+	// hint, that worker's steal will have overwritten LeaseOwner, so our
+	// renewal fails the condition and we stop consuming it here.
+	for shardID, consumer := range w.consumers {
+		acquired, err := checkpoint.TryAcquireLease(w.dbClient, w.streamName, shardID, w.workerID)
+		if err != nil {
+			log.Errorf("Failed to renew lease for shard %s: %+v", shardID, err)
+			continue
+		}
+		if !acquired {
+			consumer.shutdown(kc.ZOMBIE)
+			delete(w.consumers, shardID)
+			if w.mService != nil {
+				w.mService.LeaseLost(shardID)
+			}
+		}
+	}
+
+	for _, shard := range shardsOut.Shards {
+		shardID := aws.StringValue(shard.ShardId)
+		if _, exists := w.consumers[shardID]; exists {
+			continue
+		}
+
+		if len(w.consumers) >= w.kclConfig.MaxLeasesForWorker {
+			break
+		}
+
+		acquired, err := checkpoint.TryAcquireLease(w.dbClient, w.streamName, shardID, w.workerID)
+		if err != nil {
+			log.Errorf("Failed to acquire lease for shard %s: %+v", shardID, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		consumer := newShardConsumer(w, shardID)
+		w.consumers[shardID] = consumer
+
+		if w.mService != nil {
+			w.mService.LeaseGained(shardID)
+		}
+
+		w.done.Add(1)
+		go func() {
+			defer w.done.Done()
+			consumer.run()
+		}()
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
 
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
@@ -109,6 +110,119 @@ func TestWorkerAssumeRole(t *testing.T) {
 	runTest(kclConfig, t)
 }
 
+// localStackEndpoint is the default LocalStack edge port. Pair with
+// KINESIS_INITIALIZE_STREAMS=staging.identity:1 to have LocalStack create the
+// stream this test publishes to before it runs.
+const localStackEndpoint = "http://localhost:4566"
+
+// TestWorkerLocalStack runs the worker against LocalStack instead of real
+// AWS, so it (unlike TestWorker) can run in CI without live credentials.
+func TestWorkerLocalStack(t *testing.T) {
+	if os.Getenv("KINESIS_INITIALIZE_STREAMS") == "" {
+		t.Skip("Set KINESIS_INITIALIZE_STREAMS and run a local LocalStack instance to run this test")
+	}
+
+	kclConfig := cfg.NewKinesisClientLibConfig("appName", tableName, regionName, workerID).
+		WithInitialPositionInStream(cfg.TRIM_HORIZON).
+		WithMaxRecords(10).
+		WithMaxLeasesForWorker(1).
+		WithShardSyncIntervalMillis(500).
+		WithFailoverTimeMillis(300000).
+		WithMetricsBufferTimeMillis(10000).
+		WithMetricsMaxQueueSize(20).
+		WithKinesisEndpoint(localStackEndpoint).
+		WithDynamoDBEndpoint(localStackEndpoint).
+		WithCloudWatchEndpoint(localStackEndpoint).
+		WithS3ForcePathStyle(true)
+
+	runTestLocalStack(kclConfig, t)
+}
+
+// runTestLocalStack seeds the pre-created LocalStack stream with records
+// via PutRecords and asserts the worker's checkpoint in the DynamoDB lease
+// table advances past its initial state.
+func runTestLocalStack(kclConfig *cfg.KinesisClientLibConfiguration, t *testing.T) {
+	log.SetOutput(os.Stdout)
+	log.SetLevel(log.DebugLevel)
+
+	worker := NewWorker(&localStackRecordProcessorFactory{t: t}, kclConfig, nil)
+
+	err := worker.Start()
+	assert.Nil(t, err)
+
+	entries := make([]*kinesis.PutRecordsRequestEntry, 0, 100)
+	for i := 0; i < 100; i++ {
+		entries = append(entries, &kinesis.PutRecordsRequestEntry{
+			Data:         []byte(fmt.Sprintf(`{"id":%d}`, i)),
+			PartitionKey: aws.String(utils.MustNewUUID()),
+		})
+	}
+
+	_, err = worker.kinesisClient.PutRecords(&kinesis.PutRecordsInput{
+		StreamName: aws.String(kclConfig.StreamName),
+		Records:    entries,
+	})
+	assert.Nil(t, err)
+
+	// wait a few seconds for the worker to lease the shard, consume the
+	// records and checkpoint its progress
+	time.Sleep(10 * time.Second)
+
+	shardsOut, err := worker.kinesisClient.ListShards(&kinesis.ListShardsInput{
+		StreamName: aws.String(kclConfig.StreamName),
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, shardsOut.Shards)
+
+	leaseOut, err := worker.dbClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(kclConfig.StreamName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ShardID": {S: shardsOut.Shards[0].ShardId},
+		},
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, aws.StringValue(leaseOut.Item["Checkpoint"].S))
+
+	worker.Shutdown()
+}
+
+// localStackRecordProcessorFactory creates a processor for
+// TestWorkerLocalStack, which publishes plain JSON test payloads rather
+// than real DynamoDB Streams records, so (unlike dumpRecordProcessor) it
+// must not assume Record.Dynamodb is populated.
+type localStackRecordProcessorFactory struct {
+	t *testing.T
+}
+
+func (f *localStackRecordProcessorFactory) CreateProcessor() kc.IRecordProcessor {
+	return &localStackRecordProcessor{t: f.t}
+}
+
+type localStackRecordProcessor struct {
+	t        *testing.T
+	received int
+}
+
+func (p *localStackRecordProcessor) Initialize(input *kc.InitializationInput) {
+	p.t.Logf("Processing ShardId: %v at checkpoint: %v", input.ShardId, aws.StringValue(input.ExtendedSequenceNumber.SequenceNumber))
+}
+
+func (p *localStackRecordProcessor) ProcessRecords(input *kc.ProcessRecordsInput) {
+	if len(input.Records) == 0 {
+		return
+	}
+
+	p.received += len(input.Records)
+	p.t.Logf("Received %d records (%d total), MillisBehindLatest = %v", len(input.Records), p.received, input.MillisBehindLatest)
+	input.Checkpointer.Checkpoint(aws.String(fmt.Sprintf("%d", p.received)))
+}
+
+func (p *localStackRecordProcessor) Shutdown(input *kc.ShutdownInput) {
+	if input.ShutdownReason == kc.TERMINATE {
+		input.Checkpointer.Checkpoint(nil)
+	}
+}
+
 func runTest(kclConfig *cfg.KinesisClientLibConfiguration, t *testing.T) {
 	log.SetOutput(os.Stdout)
 	log.SetLevel(log.DebugLevel)